@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"math/big"
+)
+
+// b58Alphabet is the Base58 alphabet used by Bitcoin-style address encoding
+//
+// Base58编码使用的字符表，去掉了容易混淆的0、O、I、l
+var b58Alphabet = []byte("123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz")
+
+// Base58Encode encodes a byte array into a Base58 string; it adds no checksum
+//
+// 将字节数组编码为Base58字符串
+func Base58Encode(input []byte) []byte {
+	var result []byte
+
+	x := big.NewInt(0).SetBytes(input)
+
+	base := big.NewInt(int64(len(b58Alphabet)))
+	zero := big.NewInt(0)
+	mod := &big.Int{}
+
+	for x.Cmp(zero) != 0 {
+		x.DivMod(x, base, mod)
+		result = append(result, b58Alphabet[mod.Int64()])
+	}
+
+	// reverse, 因为上面的计算是从低位到高位
+	ReverseBytes(result)
+
+	// 保留前导的0x00字节，Base58将其表示为字符表的第一个字符
+	for _, b := range input {
+		if b == 0x00 {
+			result = append([]byte{b58Alphabet[0]}, result...)
+		} else {
+			break
+		}
+	}
+
+	return result
+}
+
+// Base58Decode decodes a Base58 string back into a byte array; it checks no checksum
+//
+// 将Base58字符串解码为字节数组
+func Base58Decode(input []byte) []byte {
+	result := big.NewInt(0)
+
+	base := big.NewInt(int64(len(b58Alphabet)))
+
+	for _, b := range input {
+		charIndex := bytes.IndexByte(b58Alphabet, b)
+		result.Mul(result, base)
+		result.Add(result, big.NewInt(int64(charIndex)))
+	}
+
+	decoded := result.Bytes()
+
+	// 恢复前导的0x00字节
+	for _, b := range input {
+		if b == b58Alphabet[0] {
+			decoded = append([]byte{0x00}, decoded...)
+		} else {
+			break
+		}
+	}
+
+	return decoded
+}
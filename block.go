@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"time"
+)
+
+// Block represents a block in the blockchain
+//
+// Block表示区块链中的一个区块
+type Block struct {
+	Timestamp     int64          // 区块创建时间
+	Transactions  []*Transaction // 区块中包含的交易列表
+	PrevBlockHash []byte         // 上一个区块的哈希值
+	Hash          []byte         // 当前区块的哈希值
+	MerkleRoot    []byte         // 交易列表的Merkle树根哈希
+	Nonce         int            // 工作量证明计算出的随机数
+}
+
+// NewBlock creates and returns a new mined Block
+//
+// 根据上一个区块的哈希值和交易列表，计算Merkle根、工作量证明并创建一个新的区块
+func NewBlock(prevBlockHash []byte, txs []*Transaction) *Block {
+	block := &Block{
+		Timestamp:     time.Now().Unix(),
+		Transactions:  txs,
+		PrevBlockHash: prevBlockHash,
+		Hash:          []byte{},
+		Nonce:         0,
+	}
+	block.MerkleRoot = block.HashTransactions()
+
+	pow := NewProofOfWork(block)
+	nonce, hash := pow.Run()
+
+	block.Hash = hash
+	block.Nonce = nonce
+
+	return block
+}
+
+// GenesisBlock creates and returns the genesis block of the blockchain
+//
+// 创建并返回区块链的创世区块
+func GenesisBlock(coinbase *Transaction) *Block {
+	return NewBlock([]byte{}, []*Transaction{coinbase})
+}
+
+// HashTransactions builds a Merkle tree over the block's transactions and returns its root hash
+//
+// 以每笔交易序列化后的字节作为叶子节点构建Merkle树，返回根哈希，用于参与工作量证明计算
+func (b *Block) HashTransactions() []byte {
+	var txData [][]byte
+
+	for _, tx := range b.Transactions {
+		txData = append(txData, tx.Serialize())
+	}
+
+	mTree := NewMerkleTree(txData)
+
+	return mTree.RootNode.Data
+}
+
+// Serialize encodes the block into a byte array using gob
+//
+// 使用gob将区块编码为字节数组，方便存入BoltDB
+func (b *Block) Serialize() []byte {
+	var result bytes.Buffer
+
+	encoder := gob.NewEncoder(&result)
+	err := encoder.Encode(b)
+	if err != nil {
+		panic(err)
+	}
+
+	return result.Bytes()
+}
+
+// Deserialize decodes a byte array back into a Block. Callers reading trusted
+// local data treat a decode failure as corruption and may panic; callers
+// processing data from a peer must check the error instead
+//
+// 将字节数组解码为Block
+// 调用方如果处理的是本地可信数据，可以把解码失败当成数据损坏直接panic；
+// 如果处理的是来自对端的数据，则必须检查返回的error
+func Deserialize(data []byte) (*Block, error) {
+	var block Block
+
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&block); err != nil {
+		return nil, err
+	}
+
+	return &block, nil
+}
+
+// String returns a human-readable representation of the block
+//
+// 返回区块的可读字符串，便于打印调试
+func (b *Block) String() string {
+	return fmt.Sprintf("Timestamp: %d, Hash: %x, PrevBlockHash: %x, Nonce: %d, Transactions: %d",
+		b.Timestamp, b.Hash, b.PrevBlockHash, b.Nonce, len(b.Transactions))
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/boltdb/bolt"
 )
@@ -12,8 +15,15 @@ const (
 )
 
 type Blockchain struct {
-	topHash []byte   // 最新区块的哈希值
-	db      *bolt.DB // 数据库
+	mu      sync.Mutex // 保护topHash，以及AddBlock/AddExistingBlock对"链接到当前链顶"的检查与写入的原子性
+	topHash []byte     // 最新区块的哈希值
+	db      *bolt.DB   // 数据库
+
+	// onBlockAdded, when set, is invoked with every block appended via AddBlock
+	// or AddExistingBlock; P2P nodes use it to broadcast the new tip to their peers
+	//
+	// onBlockAdded在每次AddBlock或AddExistingBlock追加区块后被调用；P2P节点用它向对端广播新的链顶
+	onBlockAdded func(*Block)
 }
 
 type BlockchainIterator struct {
@@ -27,20 +37,35 @@ type BlockchainIterator struct {
 //
 // 返回最新区块的哈希值
 func (bc *Blockchain) GetTopHash() []byte {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
 	return bc.topHash
 }
 
-// CreateBlockchain creates a new blockchain DB
+// CreateBlockchain opens the blockchain DB at DBFILE, creating it with a genesis
+// block whose coinbase rewards address if it does not exist yet
 //
-// 创建一个新的区块链并且添加一个创世区块
-func CreateBlockchain() *Blockchain {
+// 打开DBFILE处的区块链；如果还不存在，则创建一个新的区块链并添加创世区块，创世区块的coinbase奖励发放给address
+func CreateBlockchain(address string) *Blockchain {
+	return createBlockchainAt(DBFILE, address)
+}
+
+// createBlockchainAt creates (or opens) a blockchain DB at the given path, rewarding
+// the genesis coinbase to address. This is shared by the CLI's single-node DBFILE
+// and by per-node DB files used when several nodes run on one machine.
+//
+// 在指定路径创建（或打开）区块链数据库，创世区块的coinbase奖励发放给address
+// 单节点CLI使用固定的DBFILE，多节点场景下每个节点使用各自独立的数据库文件，共用这份逻辑
+func createBlockchainAt(dbFile, address string) *Blockchain {
 	// 0600 文件拥有者具有读写权限，其他人无任何权限
-	boltDB, err := bolt.Open(DBFILE, 0600, nil)
+	boltDB, err := bolt.Open(dbFile, 0600, nil)
 	if err != nil {
 		panic(err)
 	}
 
 	var tophash []byte // 最新区块的哈希值
+	isNewChain := false
 	// update the blockchain
 	err = boltDB.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(BLOCKBUCKET))
@@ -48,7 +73,8 @@ func CreateBlockchain() *Blockchain {
 		// if bucket is nil, then create a new blockchain
 		if bucket == nil {
 			// create a genesisblock
-			genesisBlock := GenesisBlock()
+			coinbase := NewCoinbaseTX(address, "")
+			genesisBlock := GenesisBlock(coinbase)
 
 			// 创建一个新的bucket
 			bucket, err = tx.CreateBucket([]byte(BLOCKBUCKET))
@@ -67,6 +93,7 @@ func CreateBlockchain() *Blockchain {
 				panic(err)
 			}
 			tophash = genesisBlock.Hash
+			isNewChain = true
 
 		} else {
 			// genesis block already exists,
@@ -82,13 +109,80 @@ func CreateBlockchain() *Blockchain {
 
 	blockchain := Blockchain{topHash: tophash, db: boltDB}
 
+	// 新建的区块链需要对UTXO集合做一次全量重建
+	if isNewChain {
+		UTXOSet{Blockchain: &blockchain}.Reindex()
+	}
+
 	return &blockchain
 }
 
-// AddBlock update the latest block into the blockchain
+// openExistingBlockchain opens a blockchain DB that is already known to exist at dbFile
+//
+// 打开一个已经存在的区块链数据库文件
+func openExistingBlockchain(dbFile string) *Blockchain {
+	boltDB, err := bolt.Open(dbFile, 0600, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	var tophash []byte
+	err = boltDB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(BLOCKBUCKET))
+		tophash = bucket.Get([]byte("latest"))
+
+		// 旧版本创建的数据库可能还没有utxo bucket，补建一个，避免后续Update在nil bucket上panic
+		_, err := tx.CreateBucketIfNotExists([]byte(utxoBucket))
+		return err
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return &Blockchain{topHash: tophash, db: boltDB}
+}
+
+// newSyncingBlockchain opens (or creates) a blockchain DB at dbFile with no
+// genesis block, for a node that is expected to download its chain from a peer
+// instead of mining its own
+//
+// 打开（或创建）一个没有创世区块的区块链数据库，供需要从对端同步链而不是自己挖创世区块的节点使用
+func newSyncingBlockchain(dbFile string) *Blockchain {
+	boltDB, err := bolt.Open(dbFile, 0600, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	err = boltDB.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(BLOCKBUCKET)); err != nil {
+			return err
+		}
+		// 同步节点一开始没有任何区块，但UTXOSet.Update会在收到第一个区块时写入该bucket，
+		// 必须提前创建，否则会在nil bucket上调用Put而panic
+		_, err := tx.CreateBucketIfNotExists([]byte(utxoBucket))
+		return err
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return &Blockchain{topHash: nil, db: boltDB}
+}
+
+// AddBlock mines a new block over txs and appends it to the blockchain.
+// It returns an error, rather than panicking, if any transaction fails verification
 //
 // 根据最新区块的哈希值和交易列表，创建一个新的区块，并更新区块链
-func (bc *Blockchain) AddBlock(txs []*Transaction) bool {
+// 如果交易列表中存在任何一笔签名校验不通过的交易，整个区块都会被拒绝
+func (bc *Blockchain) AddBlock(txs []*Transaction) error {
+	for _, tx := range txs {
+		if !bc.VerifyTransaction(tx) {
+			return errors.New("block contains an invalid transaction")
+		}
+	}
+
+	bc.mu.Lock()
+
 	var tophash []byte
 
 	// get the latest block hash
@@ -98,38 +192,90 @@ func (bc *Blockchain) AddBlock(txs []*Transaction) bool {
 		return nil
 	})
 	if err != nil {
+		bc.mu.Unlock()
 		panic(err)
 	}
 
 	// create a new block according to the latest block hash and transactions
 	newBlock := NewBlock(tophash, txs)
 
-	// update the blockchain
-	bc.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(BLOCKBUCKET))
-		// put the new block and block hash into the bucket
-		err := bucket.Put(newBlock.Hash, newBlock.Serialize())
-		if err != nil {
-			panic(err)
+	bc.persistBlock(newBlock)
+	bc.mu.Unlock()
+
+	// onBlockAdded在锁外调用，它可能回调到GetTopHash等同样会加锁的方法
+	if bc.onBlockAdded != nil {
+		bc.onBlockAdded(newBlock)
+	}
+
+	return nil
+}
+
+// AddExistingBlock appends a block that was already mined and hashed by a peer.
+// The block is rejected unless it links directly onto the current tip, so the
+// caller must apply out-of-order blocks oldest-first.
+//
+// 追加一个已经由对端挖出并计算好哈希的区块，不重新计算工作量证明
+// 只有直接接在当前链顶上的区块才会被接受，调用方需要按从旧到新的顺序应用乱序到达的区块
+func (bc *Blockchain) AddExistingBlock(block *Block) error {
+	if !NewProofOfWork(block).Validate() {
+		return errors.New("block failed proof-of-work validation")
+	}
+
+	for _, tx := range block.Transactions {
+		if !bc.VerifyTransaction(tx) {
+			return errors.New("block contains an invalid transaction")
 		}
+	}
 
-		// update the latest block hash
-		err = bucket.Put([]byte("latest"), newBlock.Hash)
-		if err != nil {
-			panic(err)
+	bc.mu.Lock()
+
+	// 只接受能够直接接到当前链顶的区块，避免在祖先区块缺失的情况下把链顶推进到一个
+	// 悬空的区块上，导致之后的Iterator遍历在Deserialize处崩溃
+	// 检查和写入必须在同一把锁内完成，否则两个并发到达的区块可能都读到同一个旧的
+	// topHash、都通过检查，分叉成两条并列的链并各自更新一次UTXO缓存
+	if !bytes.Equal(block.PrevBlockHash, bc.topHash) {
+		bc.mu.Unlock()
+		return errors.New("block does not link onto the current tip")
+	}
+
+	bc.persistBlock(block)
+	bc.mu.Unlock()
+
+	// onBlockAdded在锁外调用，它可能回调到GetTopHash等同样会加锁的方法
+	if bc.onBlockAdded != nil {
+		bc.onBlockAdded(block)
+	}
+
+	return nil
+}
+
+// persistBlock writes an already-built block to the blocks bucket and updates
+// the chain tip and UTXO cache. The caller must already hold bc.mu, and is
+// responsible for invoking onBlockAdded itself once it has released the lock
+//
+// 将一个已经构建好的区块写入blocks bucket，更新链顶和UTXO缓存
+// 调用方必须已经持有bc.mu，并且在释放锁之后自行负责调用onBlockAdded
+func (bc *Blockchain) persistBlock(block *Block) {
+	err := bc.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(BLOCKBUCKET))
+
+		if err := bucket.Put(block.Hash, block.Serialize()); err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte("latest"), block.Hash); err != nil {
+			return err
 		}
 
-		// update the latest block hash
-		bc.topHash = newBlock.Hash
+		bc.topHash = block.Hash
 
 		return nil
 	})
-
 	if err != nil {
 		panic(err)
 	}
 
-	return true
+	// 区块写入成功后，增量更新UTXO缓存
+	UTXOSet{Blockchain: bc}.Update(block)
 }
 
 // ---------------------------- 以下是区块链迭代器 ----------------------------
@@ -153,10 +299,13 @@ func (bit *BlockchainIterator) Next() *Block {
 		bucket := tx.Bucket([]byte(BLOCKBUCKET))
 		// get a block according to the current hash
 		serializedBlock := bucket.Get(bit.currentHash)
-		block = Deserialize(serializedBlock)
-		return nil
+
+		var err error
+		block, err = Deserialize(serializedBlock)
+		return err
 	})
 	if err != nil {
+		// 本地数据库中的数据被认为是可信的，解码失败说明数据已经损坏
 		panic(err)
 	}
 
@@ -183,10 +332,102 @@ func (bc *Blockchain) IterateBlockchain() {
 
 }
 
-// FindUnspendTransaction finds all unspend transactions according to the address
+// FindTransaction finds a transaction by its ID by scanning the whole chain
 //
-// 根据给定的地址，找到这个地址所没有花费的输出所在的交易
-func (bc *Blockchain) FindUnspendTransaction(address string) []*Transaction {
+// 根据交易ID遍历整条链查找对应的交易
+func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
+	bcIterator := bc.Iterator()
+
+	for {
+		block := bcIterator.Next()
+
+		for _, tx := range block.Transactions {
+			if bytes.Equal(tx.ID, ID) {
+				return *tx, nil
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return Transaction{}, errors.New("transaction is not found")
+}
+
+// VerifyTransaction verifies a transaction's input signatures against the referenced outputs
+//
+// 根据交易引用的上一笔交易，验证这笔交易的签名是否合法
+func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
+	if tx.IsCoinbase() {
+		return true
+	}
+
+	prevTXs := make(map[string]Transaction)
+
+	for _, in := range tx.In {
+		prevTX, err := bc.FindTransaction(in.TXid)
+		if err != nil {
+			// 引用的上一笔交易在链上找不到，视为验证失败，而不是让调用方崩溃
+			// 这种情况在处理对端发来的交易/区块时很正常，比如引用了本地还没同步到的交易
+			return false
+		}
+		prevTXs[string(prevTX.ID)] = prevTX
+	}
+
+	return tx.Verify(prevTXs)
+}
+
+// FindAllUTXO scans the whole chain once and returns every transaction's unspent outputs
+//
+// 扫描整条链一次，返回每笔交易中尚未被花费的输出，用于构建UTXOSet
+func (bc *Blockchain) FindAllUTXO() map[string][]TXoutput {
+	UTXO := make(map[string][]TXoutput)
+	spendTxos := make(map[string][]int)
+
+	bcIterator := bc.Iterator()
+
+	for {
+		block := bcIterator.Next()
+
+		for _, tx := range block.Transactions {
+			txID := string(tx.ID)
+
+		Outputs:
+			for outIdx, out := range tx.Out {
+				if spendTxos[txID] != nil {
+					for _, spentOutIdx := range spendTxos[txID] {
+						if spentOutIdx == outIdx {
+							continue Outputs
+						}
+					}
+				}
+
+				outs := UTXO[txID]
+				outs = append(outs, out)
+				UTXO[txID] = outs
+			}
+
+			if !tx.IsCoinbase() {
+				for _, in := range tx.In {
+					inTxID := string(in.TXid)
+					spendTxos[inTxID] = append(spendTxos[inTxID], in.Voutindex)
+				}
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return UTXO
+}
+
+// FindUnspendTransaction finds all unspend transactions according to the public key hash
+//
+// 根据给定的公钥哈希，找到这个地址所没有花费的输出所在的交易
+func (bc *Blockchain) FindUnspendTransaction(pubKeyHash []byte) []*Transaction {
 	// 关于addr的所有未花费的交易，在这些交易中一定包含有某个output是属于addr的
 	// 但是，这些交易中可能还有其他output也是属于addr的，所以我们需要遍历这些交易，找到所有属于addr的output
 	unsepentTXs := []*Transaction{}
@@ -219,9 +460,9 @@ func (bc *Blockchain) FindUnspendTransaction(address string) []*Transaction {
 				}
 
 				// if the outout not been used,
-				// if the output can be unlocked by the address,
+				// if the output is locked with pubKeyHash,
 				// it means that the address has not spent this output
-				if output.CanBeUnlockedWith(address) {
+				if output.IsLockedWithKey(pubKeyHash) {
 					// eg. tx #3 有3笔输出，其中第一笔输出被使用了，那么spendTxos[tx #3] = []int{0}
 					// 剩下的两笔输出中只有第二笔是给bob的，所以unsepentTXs = []*Transaction{tx #3}
 					// 说明tx #3中存在关于bob的未花费输出
@@ -232,9 +473,8 @@ func (bc *Blockchain) FindUnspendTransaction(address string) []*Transaction {
 			// tx can have input only if it is not a coinbase transaction
 			if !tx.IsCoinbase() {
 				for _, input := range tx.In {
-					// if the input can unlock the output with the address,
-					// it means that the address has spent the output
-					if input.CanUnlockOutputWith(address) {
+					// if the input uses the same key, it means that the address has spent the output
+					if input.UsesKey(pubKeyHash) {
 						inputTxID := string(input.TXid)
 						// inputTxID 记录了上一笔交易的ID
 						// input.Voutindex 记录了上一笔交易中的具体哪一笔输出被使用了
@@ -254,21 +494,56 @@ func (bc *Blockchain) FindUnspendTransaction(address string) []*Transaction {
 	return unsepentTXs
 }
 
-// FindUTXO finds all unspent transaction outputs according to the address
+// GetBalance sums the value of all cached unspent outputs belonging to address
+//
+// 累加address名下所有缓存的未花费输出的金额，得到余额
+func (bc *Blockchain) GetBalance(address string) int {
+	pubKeyHash := Base58Decode([]byte(address))
+	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
+
+	balance := 0
+	utxoSet := UTXOSet{Blockchain: bc}
+	for _, out := range utxoSet.FindUTXO(pubKeyHash) {
+		balance += out.Value
+	}
+
+	return balance
+}
+
+// Send builds a transfer transaction from from to to and mines it together with a miner reward
+//
+// 构建一笔从from到to的转账交易，连同矿工奖励一起打包进新区块
+func (bc *Blockchain) Send(from, to string, amount int, minerAddress string) error {
+	tx, err := NewUTXOTransaction(from, to, amount, bc)
+	if err != nil {
+		return err
+	}
+
+	// 把当前链顶哈希混进data里，确保连续奖励给同一地址的coinbase交易ID不会重复，
+	// 否则UTXOSet.Update会用新交易的ID覆盖上一个区块的coinbase输出
+	coinbaseTX := NewCoinbaseTX(minerAddress, fmt.Sprintf("Reward to '%s' after %x", minerAddress, bc.GetTopHash()))
+
+	return bc.AddBlock([]*Transaction{coinbaseTX, tx})
+}
+
+// FindUTXO finds all unspent transaction outputs according to the public key hash
 //
-// 根据给定的地址，找到这个地址在当前区块链中所没有花费的输出，需要使用FindUnspendTransaction函数
-func (bc *Blockchain) FindUTXO(addr string) []*TXoutput {
+// 根据给定的公钥哈希，找到这个地址在当前区块链中所没有花费的输出，需要使用FindUnspendTransaction函数
+func (bc *Blockchain) FindUTXO(pubKeyHash []byte) []*TXoutput {
 	UTXOs := []*TXoutput{}
 
-	unspentTxs := bc.FindUnspendTransaction(addr)
+	unspentTxs := bc.FindUnspendTransaction(pubKeyHash)
 
 	// iterate over all transactions
 	for _, tx := range unspentTxs {
 		// iterate over all outputs in one transaction
 		for _, output := range tx.Out {
-			// if the output can be unlocked by the address,
+			// output是range复用的循环变量，不能直接取地址，否则多笔输出会共享同一个
+			// 变量的地址，后面读出来的值全部变成最后一次迭代写入的内容
+			output := output
+			// if the output is locked with pubKeyHash,
 			// it means that this output belongs to the address
-			if output.CanBeUnlockedWith(addr) {
+			if output.IsLockedWithKey(pubKeyHash) {
 				UTXOs = append(UTXOs, &output)
 			}
 		}
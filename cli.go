@@ -0,0 +1,240 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// CLI wraps the command-line interface for the blockchain
+//
+// CLI封装了区块链的命令行交互入口
+type CLI struct{}
+
+// printUsage prints the CLI usage instructions
+//
+// 打印命令行使用说明
+func (cli *CLI) printUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  createwallet - Generates a new key-pair and saves it into the wallet file")
+	fmt.Println("  listaddresses - Lists all addresses from the wallet file")
+	fmt.Println("  createblockchain -address ADDRESS - Create a blockchain and send genesis block reward to ADDRESS")
+	fmt.Println("  getbalance -address ADDRESS - Get balance of ADDRESS")
+	fmt.Println("  send -from FROM -to TO -amount AMOUNT - Send AMOUNT of coins from FROM address to TO, mined by FROM")
+	fmt.Println("  printchain - Print all the blocks of the blockchain")
+	fmt.Println("  startnode -miner ADDRESS - Start a node identified by the NODE_ID env var, optionally mining to ADDRESS")
+}
+
+// validateArgs checks that at least one subcommand was given
+//
+// 检查是否至少传入了一个子命令
+func (cli *CLI) validateArgs() {
+	if len(os.Args) < 2 {
+		cli.printUsage()
+		os.Exit(1)
+	}
+}
+
+// Run parses the command-line arguments and runs the corresponding command
+//
+// 解析命令行参数并执行对应的命令
+func (cli *CLI) Run() {
+	cli.validateArgs()
+
+	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
+	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
+	createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
+	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
+	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
+	startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
+
+	createBlockchainAddress := createBlockchainCmd.String("address", "", "The address to send genesis block reward to")
+	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
+	sendFrom := sendCmd.String("from", "", "Source wallet address")
+	sendTo := sendCmd.String("to", "", "Destination wallet address")
+	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
+	startNodeMiner := startNodeCmd.String("miner", "", "Enable mining mode and send the reward to this address")
+
+	switch os.Args[1] {
+	case "createwallet":
+		cli.parseOrExit(createWalletCmd)
+	case "listaddresses":
+		cli.parseOrExit(listAddressesCmd)
+	case "createblockchain":
+		cli.parseOrExit(createBlockchainCmd)
+	case "getbalance":
+		cli.parseOrExit(getBalanceCmd)
+	case "send":
+		cli.parseOrExit(sendCmd)
+	case "printchain":
+		cli.parseOrExit(printChainCmd)
+	case "startnode":
+		cli.parseOrExit(startNodeCmd)
+	default:
+		cli.printUsage()
+		os.Exit(1)
+	}
+
+	if createWalletCmd.Parsed() {
+		cli.createWallet()
+	}
+
+	if listAddressesCmd.Parsed() {
+		cli.listAddresses()
+	}
+
+	if createBlockchainCmd.Parsed() {
+		if *createBlockchainAddress == "" {
+			createBlockchainCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createBlockchain(*createBlockchainAddress)
+	}
+
+	if getBalanceCmd.Parsed() {
+		if *getBalanceAddress == "" {
+			getBalanceCmd.Usage()
+			os.Exit(1)
+		}
+		cli.getBalance(*getBalanceAddress)
+	}
+
+	if sendCmd.Parsed() {
+		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 {
+			sendCmd.Usage()
+			os.Exit(1)
+		}
+		cli.send(*sendFrom, *sendTo, *sendAmount)
+	}
+
+	if printChainCmd.Parsed() {
+		cli.printChain()
+	}
+
+	if startNodeCmd.Parsed() {
+		cli.startNode(*startNodeMiner)
+	}
+}
+
+// parseOrExit parses a flag set and exits the process on failure
+//
+// 解析命令行子命令的参数，解析失败则退出进程
+func (cli *CLI) parseOrExit(fs *flag.FlagSet) {
+	err := fs.Parse(os.Args[2:])
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// createWallet generates a new wallet and prints its address
+//
+// 生成一个新钱包并打印其地址
+func (cli *CLI) createWallet() {
+	wallets, _ := NewWallets()
+	address := wallets.CreateWallet()
+	wallets.SaveToFile()
+
+	fmt.Printf("Your new address: %s\n", address)
+}
+
+// listAddresses prints every address stored in the wallet file
+//
+// 打印钱包文件中保存的所有地址
+func (cli *CLI) listAddresses() {
+	wallets, err := NewWallets()
+	if err != nil {
+		panic(err)
+	}
+
+	for _, address := range wallets.GetAddresses() {
+		fmt.Println(address)
+	}
+}
+
+// createBlockchain creates a blockchain and sends the genesis reward to address
+//
+// 创建区块链，创世区块的奖励发放给address
+func (cli *CLI) createBlockchain(address string) {
+	if !ValidateAddress(address) {
+		fmt.Println("ERROR: Address is not valid")
+		os.Exit(1)
+	}
+
+	bc := CreateBlockchain(address)
+	bc.db.Close()
+
+	fmt.Println("Done!")
+}
+
+// getBalance prints the balance of address by summing its unspent outputs
+//
+// 通过累加未花费输出，打印address的余额
+func (cli *CLI) getBalance(address string) {
+	if !ValidateAddress(address) {
+		fmt.Println("ERROR: Address is not valid")
+		os.Exit(1)
+	}
+
+	bc := CreateBlockchain(address)
+	defer bc.db.Close()
+
+	balance := bc.GetBalance(address)
+
+	fmt.Printf("Balance of '%s': %d\n", address, balance)
+}
+
+// send transfers amount of coins from the from address to the to address
+//
+// 将amount数量的货币从from地址转账给to地址，新区块由from挖出
+func (cli *CLI) send(from, to string, amount int) {
+	if !ValidateAddress(from) {
+		fmt.Println("ERROR: Sender address is not valid")
+		os.Exit(1)
+	}
+	if !ValidateAddress(to) {
+		fmt.Println("ERROR: Recipient address is not valid")
+		os.Exit(1)
+	}
+
+	bc := CreateBlockchain(from)
+	defer bc.db.Close()
+
+	err := bc.Send(from, to, amount, from)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Success!")
+}
+
+// startNode starts a P2P node identified by the NODE_ID environment variable,
+// optionally mining new blocks with the reward sent to minerAddress
+//
+// 启动一个由NODE_ID环境变量标识的P2P节点，如果指定了minerAddress则该节点同时挖矿
+func (cli *CLI) startNode(minerAddress string) {
+	nodeID := os.Getenv("NODE_ID")
+	if nodeID == "" {
+		fmt.Println("ERROR: NODE_ID env var is not set")
+		os.Exit(1)
+	}
+
+	if minerAddress != "" && !ValidateAddress(minerAddress) {
+		fmt.Println("ERROR: Wrong miner address")
+		os.Exit(1)
+	}
+
+	fmt.Printf("Starting node %s\n", nodeID)
+	StartServer(nodeID, minerAddress)
+}
+
+// printChain prints every block of the blockchain, from the tip to the genesis block
+//
+// 从最新区块到创世区块，打印整条链
+func (cli *CLI) printChain() {
+	bc := CreateBlockchain("")
+	defer bc.db.Close()
+
+	bc.IterateBlockchain()
+}
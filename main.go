@@ -0,0 +1,9 @@
+package main
+
+// main is the entry point of the blockchain CLI application
+//
+// 程序入口，启动命令行交互
+func main() {
+	cli := CLI{}
+	cli.Run()
+}
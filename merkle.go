@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+// MerkleNode is a single node of a MerkleTree
+//
+// MerkleNode是Merkle树中的一个节点
+type MerkleNode struct {
+	Left  *MerkleNode
+	Right *MerkleNode
+	Data  []byte
+}
+
+// MerkleTree represents a Merkle tree built over a set of leaf data
+//
+// MerkleTree是根据一组叶子数据构建的Merkle树
+type MerkleTree struct {
+	RootNode *MerkleNode
+}
+
+// NewMerkleNode creates a MerkleNode from its children, or hashes data if it is a leaf
+//
+// 根据左右子节点创建一个MerkleNode；如果left和right都为空，则说明这是一个叶子节点，直接对data做哈希
+func NewMerkleNode(left, right *MerkleNode, data []byte) *MerkleNode {
+	node := MerkleNode{}
+
+	if left == nil && right == nil {
+		hash := sha256.Sum256(data)
+		node.Data = hash[:]
+	} else {
+		prevHashes := append(left.Data, right.Data...)
+		hash := sha256.Sum256(prevHashes)
+		node.Data = hash[:]
+	}
+
+	node.Left = left
+	node.Right = right
+
+	return &node
+}
+
+// NewMerkleTree builds a MerkleTree over the given leaf data
+//
+// 根据叶子数据构建Merkle树：每层节点数为奇数时，复制最后一个节点凑成偶数，逐层两两哈希直到只剩根节点
+func NewMerkleTree(data [][]byte) *MerkleTree {
+	var nodes []MerkleNode
+
+	for _, datum := range data {
+		node := NewMerkleNode(nil, nil, datum)
+		nodes = append(nodes, *node)
+	}
+
+	if len(nodes) == 0 {
+		nodes = append(nodes, *NewMerkleNode(nil, nil, []byte{}))
+	}
+
+	for len(nodes) > 1 {
+		if len(nodes)%2 != 0 {
+			nodes = append(nodes, nodes[len(nodes)-1])
+		}
+
+		var newLevel []MerkleNode
+
+		for i := 0; i < len(nodes); i += 2 {
+			node := NewMerkleNode(&nodes[i], &nodes[i+1], nil)
+			newLevel = append(newLevel, *node)
+		}
+
+		nodes = newLevel
+	}
+
+	tree := MerkleTree{RootNode: &nodes[0]}
+
+	return &tree
+}
+
+// Proof returns the sibling hashes and left/right flags needed to verify that
+// the leaf txID is included in the tree. txID is the raw leaf data as passed to
+// NewMerkleTree (for a block's tree, tx.Serialize()), not a transaction's ID
+//
+// 返回验证叶子数据txID包含在树中所需的兄弟节点哈希，以及每一步兄弟节点在左边还是右边
+// txID是构建树时传入NewMerkleTree的原始叶子数据（区块的树中即tx.Serialize()），而不是交易ID
+func (t *MerkleTree) Proof(txID []byte) ([][]byte, []bool, error) {
+	leafHash := sha256.Sum256(txID)
+
+	var path [][]byte
+	var isLeft []bool
+
+	var find func(node *MerkleNode, target []byte, trail [][]byte, leftFlags []bool) ([][]byte, []bool, bool)
+	find = func(node *MerkleNode, target []byte, trail [][]byte, leftFlags []bool) ([][]byte, []bool, bool) {
+		if node == nil {
+			return nil, nil, false
+		}
+
+		if node.Left == nil && node.Right == nil {
+			if bytes.Equal(node.Data, target) {
+				return trail, leftFlags, true
+			}
+			return nil, nil, false
+		}
+
+		if node.Left != nil {
+			if p, f, ok := find(node.Left, target, append(trail, node.Right.Data), append(leftFlags, false)); ok {
+				return p, f, true
+			}
+		}
+		if node.Right != nil {
+			if p, f, ok := find(node.Right, target, append(trail, node.Left.Data), append(leftFlags, true)); ok {
+				return p, f, true
+			}
+		}
+
+		return nil, nil, false
+	}
+
+	path, isLeft, found := find(t.RootNode, leafHash[:], nil, nil)
+	if !found {
+		return nil, nil, errors.New("transaction not found in merkle tree")
+	}
+
+	// find() walks root-to-leaf, but verification walks leaf-to-root, so reverse both slices
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+		isLeft[i], isLeft[j] = isLeft[j], isLeft[i]
+	}
+
+	return path, isLeft, nil
+}
+
+// VerifyMerkleProof checks that leaf, combined with the sibling hashes in proof, produces root
+//
+// 验证leaf结合proof中的兄弟哈希逐层计算后是否能得到root，用于SPV客户端校验交易是否被打包进区块
+func VerifyMerkleProof(root, leaf []byte, proof [][]byte, flags []bool) bool {
+	if len(proof) != len(flags) {
+		return false
+	}
+
+	hash := sha256.Sum256(leaf)
+	current := hash[:]
+
+	for i, sibling := range proof {
+		var combined []byte
+		// flags[i] == true表示兄弟节点在左边，当前节点在右边
+		if flags[i] {
+			combined = append(sibling, current...)
+		} else {
+			combined = append(current, sibling...)
+		}
+		next := sha256.Sum256(combined)
+		current = next[:]
+	}
+
+	return bytes.Equal(current, root)
+}
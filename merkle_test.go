@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMerkleTreeOddLeafCountDuplicatesLast(t *testing.T) {
+	leaves := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	tree := NewMerkleTree(leaves)
+
+	if tree.RootNode == nil || len(tree.RootNode.Data) == 0 {
+		t.Fatalf("expected a non-empty root hash")
+	}
+
+	duplicated := NewMerkleTree([][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("c")})
+	if !bytes.Equal(tree.RootNode.Data, duplicated.RootNode.Data) {
+		t.Fatalf("expected the root of [a b c] to equal the root of [a b c c]")
+	}
+}
+
+func TestMerkleProofVerifies(t *testing.T) {
+	leaves := [][]byte{[]byte("tx1"), []byte("tx2"), []byte("tx3"), []byte("tx4")}
+	tree := NewMerkleTree(leaves)
+
+	for _, leaf := range leaves {
+		proof, flags, err := tree.Proof(leaf)
+		if err != nil {
+			t.Fatalf("Proof(%s) returned error: %v", leaf, err)
+		}
+
+		if !VerifyMerkleProof(tree.RootNode.Data, leaf, proof, flags) {
+			t.Fatalf("expected proof for %s to verify against the root", leaf)
+		}
+	}
+}
+
+func TestMerkleProofRejectsTamperedLeaf(t *testing.T) {
+	leaves := [][]byte{[]byte("tx1"), []byte("tx2"), []byte("tx3"), []byte("tx4")}
+	tree := NewMerkleTree(leaves)
+
+	proof, flags, err := tree.Proof(leaves[0])
+	if err != nil {
+		t.Fatalf("Proof returned error: %v", err)
+	}
+
+	if VerifyMerkleProof(tree.RootNode.Data, []byte("not-a-real-tx"), proof, flags) {
+		t.Fatalf("expected proof to fail for a leaf that isn't in the tree")
+	}
+}
+
+func TestMerkleProofUnknownLeaf(t *testing.T) {
+	leaves := [][]byte{[]byte("tx1"), []byte("tx2")}
+	tree := NewMerkleTree(leaves)
+
+	if _, _, err := tree.Proof([]byte("missing")); err == nil {
+		t.Fatalf("expected an error when proving a leaf that is not in the tree")
+	}
+}
+
+func TestBlockMerkleRootChangesWithTransactions(t *testing.T) {
+	coinbaseA := NewCoinbaseTX(string(NewWallet().GetAddress()), "")
+	coinbaseB := NewCoinbaseTX(string(NewWallet().GetAddress()), "")
+
+	blockA := NewBlock([]byte{}, []*Transaction{coinbaseA})
+	blockB := NewBlock([]byte{}, []*Transaction{coinbaseB})
+
+	if bytes.Equal(blockA.MerkleRoot, blockB.MerkleRoot) {
+		t.Fatalf("expected blocks with different transactions to have different merkle roots")
+	}
+}
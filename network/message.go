@@ -0,0 +1,155 @@
+// Package network implements the wire protocol used by blockchain nodes to
+// exchange version, inventory, and block/transaction data over TCP.
+//
+// network包实现了区块链节点之间交换version、inv以及区块/交易数据时使用的消息格式
+package network
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// commandLength is the fixed size, in bytes, of a message's command field
+//
+// Command字段的固定长度
+const commandLength = 12
+
+// Command names for every message kind exchanged between nodes
+//
+// 节点之间交换的各类消息的命令名
+const (
+	CmdVersion   = "version"
+	CmdGetBlocks = "getblocks"
+	CmdInv       = "inv"
+	CmdGetData   = "getdata"
+	CmdBlock     = "block"
+	CmdTx        = "tx"
+	CmdAddr      = "addr"
+)
+
+// Message is the framing envelope every node sends over the wire: a fixed-size
+// command name followed by a gob-encoded payload specific to that command.
+//
+// Message是节点间通信的消息帧：固定长度的命令名，加上该命令对应的gob编码负载
+type Message struct {
+	Command [commandLength]byte
+	Payload []byte
+}
+
+// Version is sent right after a connection is established and carries the
+// sender's best chain height so peers can decide who needs to sync.
+//
+// Version在连接建立后立即发送，携带发送者当前最长链的高度，供对端判断是否需要同步
+type Version struct {
+	Version    int
+	BestHeight int
+	AddrFrom   string
+}
+
+// GetBlocks asks a peer for the hashes of the blocks it knows about
+//
+// GetBlocks向对端请求其已知的所有区块哈希
+type GetBlocks struct {
+	AddrFrom string
+}
+
+// Inv advertises a set of block or transaction hashes the sender has
+//
+// Inv用于向对端广播发送者拥有的一组区块或交易哈希
+type Inv struct {
+	AddrFrom string
+	Type     string // "block" or "tx"
+	Items    [][]byte
+}
+
+// GetData requests a single block or transaction, identified by its hash, from a peer
+//
+// GetData向对端请求某个具体的区块或交易，通过哈希标识
+type GetData struct {
+	AddrFrom string
+	Type     string // "block" or "tx"
+	ID       []byte
+}
+
+// BlockData carries a single serialized block
+//
+// BlockData携带一个序列化后的区块
+type BlockData struct {
+	AddrFrom string
+	Block    []byte
+}
+
+// TxData carries a single serialized transaction
+//
+// TxData携带一个序列化后的交易
+type TxData struct {
+	AddrFrom    string
+	Transaction []byte
+}
+
+// Addr shares a list of known peer addresses
+//
+// Addr用于分享已知的节点地址列表
+type Addr struct {
+	AddrList []string
+}
+
+// commandToBytes right-pads a command name into the fixed-size command field
+//
+// 将命令名右侧补0，填充成固定长度的命令字段
+func commandToBytes(command string) [commandLength]byte {
+	var bytesCmd [commandLength]byte
+
+	copy(bytesCmd[:], command)
+
+	return bytesCmd
+}
+
+// bytesToCommand trims the trailing zero padding off a command field
+//
+// 去掉命令字段末尾的补0字节，还原出命令名
+func bytesToCommand(bytesCmd [commandLength]byte) string {
+	return string(bytes.TrimRight(bytesCmd[:], "\x00"))
+}
+
+// EncodeMessage gob-encodes payload and frames it with the given command name
+//
+// 使用gob编码payload，并附上命令名，得到完整的消息字节流
+func EncodeMessage(command string, payload interface{}) ([]byte, error) {
+	var payloadBuf bytes.Buffer
+	if err := gob.NewEncoder(&payloadBuf).Encode(payload); err != nil {
+		return nil, fmt.Errorf("encode %s payload: %w", command, err)
+	}
+
+	msg := Message{Command: commandToBytes(command), Payload: payloadBuf.Bytes()}
+
+	var msgBuf bytes.Buffer
+	if err := gob.NewEncoder(&msgBuf).Encode(msg); err != nil {
+		return nil, fmt.Errorf("encode %s message: %w", command, err)
+	}
+
+	return msgBuf.Bytes(), nil
+}
+
+// ReadMessage reads and decodes one framed Message from r, returning its
+// command name and raw (still gob-encoded) payload
+//
+// 从r中读取并解码一个完整的Message，返回命令名以及仍是gob编码状态的payload
+func ReadMessage(r io.Reader) (string, []byte, error) {
+	var msg Message
+
+	if err := gob.NewDecoder(r).Decode(&msg); err != nil {
+		return "", nil, err
+	}
+
+	return bytesToCommand(msg.Command), msg.Payload, nil
+}
+
+// DecodePayload gob-decodes a message payload obtained from ReadMessage into out
+//
+// 将ReadMessage得到的payload解码到out指向的结构体中
+func DecodePayload(payload []byte, out interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(payload)).Decode(out)
+}
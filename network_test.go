@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// startTestNode builds a server for nodeID, starts listening, and serves
+// connections in the background. The returned stop func closes the listener.
+func startTestNode(t *testing.T, nodeID, minerAddress string) (*server, func()) {
+	t.Helper()
+
+	s := newServer(nodeID, minerAddress)
+
+	ln, err := net.Listen(protocol, s.nodeAddress)
+	if err != nil {
+		t.Fatalf("listen on %s: %v", s.nodeAddress, err)
+	}
+
+	go s.serve(ln)
+
+	return s, func() { ln.Close(); s.bc.db.Close() }
+}
+
+// waitForConvergence polls until seed, peer1 and peer2 all report the same
+// chain tip, or fails the test once timeout elapses
+func waitForConvergence(t *testing.T, seed, peer1, peer2 *server, timeout time.Duration) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		if string(seed.bc.GetTopHash()) == string(peer1.bc.GetTopHash()) &&
+			string(seed.bc.GetTopHash()) == string(peer2.bc.GetTopHash()) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("nodes did not converge: seed=%x peer1=%x peer2=%x",
+				seed.bc.GetTopHash(), peer1.bc.GetTopHash(), peer2.bc.GetTopHash())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestThreeNodesConvergeOnSameTipHash(t *testing.T) {
+	withTempChainDir(t, func() {
+		minerWallet := NewWallet()
+		recipientWallet := NewWallet()
+		minerAddress := string(minerWallet.GetAddress())
+		recipientAddress := string(recipientWallet.GetAddress())
+
+		wallets := Wallets{Wallets: map[string]*Wallet{
+			minerAddress:     minerWallet,
+			recipientAddress: recipientWallet,
+		}}
+		wallets.SaveToFile()
+
+		// the seed node mines its own genesis block; every other node starts
+		// from a genuinely empty database and must sync the chain over the wire
+		seed, stopSeed := startTestNode(t, "3000", minerAddress)
+		defer stopSeed()
+		peer1, stopPeer1 := startTestNode(t, "3001", "")
+		defer stopPeer1()
+		peer2, stopPeer2 := startTestNode(t, "3002", "")
+		defer stopPeer2()
+
+		peer1.bootstrap()
+		peer2.bootstrap()
+
+		waitForConvergence(t, seed, peer1, peer2, 5*time.Second)
+
+		if err := seed.bc.Send(minerAddress, recipientAddress, 1, minerAddress); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+
+		waitForConvergence(t, seed, peer1, peer2, 5*time.Second)
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math"
+	"math/big"
+)
+
+// targetBits defines the mining difficulty
+//
+// 挖矿难度，数值越大前导0越多，挖矿越难
+const targetBits = 16
+
+// maxNonce is the upper bound for the nonce search before giving up
+//
+// nonce的搜索上限，避免无限循环
+var maxNonce = math.MaxInt64
+
+// ProofOfWork represents a proof-of-work computation for a block
+//
+// ProofOfWork表示针对某个区块的工作量证明计算
+type ProofOfWork struct {
+	block  *Block
+	target *big.Int // 目标难度值，区块哈希必须小于这个值
+}
+
+// NewProofOfWork creates a ProofOfWork for the given block
+//
+// 根据targetBits计算目标难度值，并返回ProofOfWork
+func NewProofOfWork(b *Block) *ProofOfWork {
+	target := big.NewInt(1)
+	target.Lsh(target, uint(256-targetBits))
+
+	return &ProofOfWork{block: b, target: target}
+}
+
+// prepareData assembles the block fields and nonce into the data to be hashed
+//
+// 将区块字段和nonce拼接成参与哈希计算的数据
+func (pow *ProofOfWork) prepareData(nonce int) []byte {
+	data := bytes.Join(
+		[][]byte{
+			pow.block.PrevBlockHash,
+			pow.block.MerkleRoot,
+			IntToHex(pow.block.Timestamp),
+			IntToHex(int64(targetBits)),
+			IntToHex(int64(nonce)),
+		},
+		[]byte{},
+	)
+
+	return data
+}
+
+// Run performs the proof-of-work computation and returns the nonce and hash
+//
+// 不断尝试nonce，直到计算出的哈希值小于目标难度值
+func (pow *ProofOfWork) Run() (int, []byte) {
+	var hashInt big.Int
+	var hash [32]byte
+	nonce := 0
+
+	for nonce < maxNonce {
+		data := pow.prepareData(nonce)
+		hash = sha256.Sum256(data)
+		hashInt.SetBytes(hash[:])
+
+		if hashInt.Cmp(pow.target) == -1 {
+			break
+		}
+
+		nonce++
+	}
+
+	return nonce, hash[:]
+}
+
+// Validate checks whether the block's hash satisfies the proof-of-work target
+//
+// 验证区块的哈希值是否满足工作量证明的要求
+func (pow *ProofOfWork) Validate() bool {
+	var hashInt big.Int
+
+	data := pow.prepareData(pow.block.Nonce)
+	hash := sha256.Sum256(data)
+	hashInt.SetBytes(hash[:])
+
+	return hashInt.Cmp(pow.target) == -1
+}
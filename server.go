@@ -0,0 +1,558 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/lenny-mo/Cosmos_Build_a_blockchain/network"
+)
+
+// protocol is the transport protocol nodes communicate over
+//
+// 节点之间通信使用的传输层协议
+const protocol = "tcp"
+
+// nodeVersion is the protocol version advertised in the version message
+//
+// version消息中携带的协议版本号
+const nodeVersion = 1
+
+// mempoolThreshold is the number of pending transactions a miner node
+// accumulates before it mines them into a new block
+//
+// 矿工节点在挖出新区块之前，内存池中需要累积的交易数量
+const mempoolThreshold = 2
+
+// server holds the mutable state of a running node: its peer list, the
+// blocks it is still waiting to download, and its transaction mempool
+//
+// server保存一个运行中节点的可变状态：已知的对端列表、仍在等待下载的区块、交易内存池
+type server struct {
+	nodeAddress  string
+	minerAddress string
+	bc           *Blockchain
+
+	mu              sync.Mutex
+	knownNodes      []string
+	blocksInTransit [][]byte
+	mempool         map[string]*Transaction
+}
+
+// nodeDBFile returns the BoltDB filename for a given node, so that multiple
+// nodes can run against the same directory without clobbering each other
+//
+// 根据nodeID返回该节点使用的BoltDB文件名，使多个节点可以在同一目录下运行而不互相干扰
+func nodeDBFile(nodeID string) string {
+	return fmt.Sprintf("blockchain_%s.db", nodeID)
+}
+
+// StartServer starts a node listening on nodeID's port, optionally mining as minerAddress
+//
+// 启动一个监听在nodeID对应端口上的节点，如果minerAddress非空则该节点同时作为矿工
+func StartServer(nodeID string, minerAddress string) {
+	s := newServer(nodeID, minerAddress)
+
+	ln, err := net.Listen(protocol, s.nodeAddress)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer ln.Close()
+
+	s.bootstrap()
+	s.serve(ln)
+}
+
+// newServer builds a server for nodeID without starting to listen, so tests
+// can drive its blockchain directly before wiring up the network
+//
+// 构建一个nodeID对应的server，但不开始监听，便于测试在连接网络之前直接操作其区块链
+func newServer(nodeID, minerAddress string) *server {
+	nodeAddress := fmt.Sprintf("localhost:%s", nodeID)
+
+	bc := openNodeBlockchain(nodeID, minerAddress)
+
+	s := &server{
+		nodeAddress:  nodeAddress,
+		minerAddress: minerAddress,
+		bc:           bc,
+		mempool:      make(map[string]*Transaction),
+	}
+	bc.onBlockAdded = func(*Block) { s.broadcastBlockTip() }
+
+	return s
+}
+
+// bootstrap connects this node to the known seed node, unless it is the seed itself
+//
+// 连接到种子节点，除非自己就是种子节点
+func (s *server) bootstrap() {
+	if s.nodeAddress != knownNodesSeed {
+		s.addKnownNode(knownNodesSeed)
+		s.sendVersion(knownNodesSeed)
+	}
+}
+
+// serve accepts connections on ln until it is closed, dispatching each to handleConnection
+//
+// 持续接受ln上的连接，直到其被关闭，每个连接都交给handleConnection处理
+func (s *server) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleConnection(conn)
+	}
+}
+
+// knownNodesSeed is the well-known bootstrap node every other node dials first
+//
+// 每个新节点启动后首先尝试连接的种子节点地址
+const knownNodesSeed = "localhost:3000"
+
+// openNodeBlockchain opens (or creates) the blockchain DB belonging to nodeID.
+// Only the seed node mines its own genesis block when starting from scratch;
+// every other node starts empty and downloads the chain from a peer, so that
+// an independently-mined genesis can never diverge from the one everyone else
+// is syncing against.
+//
+// 打开（或创建）属于nodeID的区块链数据库
+// 只有种子节点会在从零启动时挖出自己的创世区块；其他节点一律从空链启动，
+// 通过与对端同步来获得完整的链，避免各自独立挖出的创世区块永远无法收敛成同一条链
+func openNodeBlockchain(nodeID, minerAddress string) *Blockchain {
+	dbFile := nodeDBFile(nodeID)
+
+	if _, err := os.Stat(dbFile); err == nil {
+		return openExistingBlockchain(dbFile)
+	}
+
+	nodeAddress := fmt.Sprintf("localhost:%s", nodeID)
+	if nodeAddress != knownNodesSeed {
+		return newSyncingBlockchain(dbFile)
+	}
+
+	if minerAddress == "" {
+		log.Panic("the seed node needs -miner ADDRESS to create its genesis block")
+	}
+
+	return createBlockchainAt(dbFile, minerAddress)
+}
+
+// handleConnection dispatches an incoming connection to the handler matching its command
+//
+// 根据连接中消息的命令类型，分发给对应的处理函数
+func (s *server) handleConnection(conn net.Conn) {
+	defer conn.Close()
+	// 连接来自未经信任的对端，任何处理过程中的panic都不应该放倒整个节点进程，
+	// 只丢掉这一个连接
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("ERROR: recovered from panic handling connection from", conn.RemoteAddr(), ":", r)
+		}
+	}()
+
+	command, payload, err := network.ReadMessage(conn)
+	if err != nil {
+		log.Println("ERROR: failed to read message:", err)
+		return
+	}
+
+	switch command {
+	case network.CmdVersion:
+		s.handleVersion(payload)
+	case network.CmdGetBlocks:
+		s.handleGetBlocks(payload)
+	case network.CmdInv:
+		s.handleInv(payload)
+	case network.CmdGetData:
+		s.handleGetData(payload)
+	case network.CmdBlock:
+		s.handleBlock(payload)
+	case network.CmdTx:
+		s.handleTx(payload)
+	case network.CmdAddr:
+		s.handleAddr(payload)
+	default:
+		log.Println("WARN: unknown command", command)
+	}
+}
+
+// sendMessage encodes command/payload and sends it to the node listening at addr
+//
+// 将command/payload编码后，发送给监听在addr的节点
+func (s *server) sendMessage(addr, command string, payload interface{}) {
+	data, err := network.EncodeMessage(command, payload)
+	if err != nil {
+		log.Println("ERROR: encode message:", err)
+		return
+	}
+
+	conn, err := net.Dial(protocol, addr)
+	if err != nil {
+		log.Println("WARN: peer", addr, "is not reachable:", err)
+		s.removeKnownNode(addr)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(data); err != nil {
+		log.Println("ERROR: send to", addr, ":", err)
+	}
+}
+
+// bestHeight returns the length of the node's current chain
+//
+// 返回当前节点区块链的高度
+func (s *server) bestHeight() int {
+	if s.bc.GetTopHash() == nil {
+		return 0
+	}
+
+	height := 0
+	it := s.bc.Iterator()
+	for {
+		block := it.Next()
+		height++
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+	return height
+}
+
+// sendVersion advertises this node's chain height to the peer at addr
+//
+// 向addr发送version消息，告知对方本节点当前的链高度
+func (s *server) sendVersion(addr string) {
+	s.sendMessage(addr, network.CmdVersion, network.Version{
+		Version:    nodeVersion,
+		BestHeight: s.bestHeight(),
+		AddrFrom:   s.nodeAddress,
+	})
+}
+
+// handleVersion compares the peer's height to ours and starts a sync if we're behind
+//
+// 比较对端的链高度和自己的，如果自己落后则发起同步
+func (s *server) handleVersion(payload []byte) {
+	var v network.Version
+	if err := network.DecodePayload(payload, &v); err != nil {
+		log.Println("ERROR: decode version:", err)
+		return
+	}
+
+	s.addKnownNode(v.AddrFrom)
+
+	myHeight := s.bestHeight()
+	if myHeight < v.BestHeight {
+		s.sendMessage(v.AddrFrom, network.CmdGetBlocks, network.GetBlocks{AddrFrom: s.nodeAddress})
+	} else if myHeight > v.BestHeight {
+		s.sendVersion(v.AddrFrom)
+	}
+}
+
+// handleGetBlocks replies with an inv listing every block hash we have
+//
+// 回复一个inv消息，列出自己拥有的所有区块哈希
+func (s *server) handleGetBlocks(payload []byte) {
+	var gb network.GetBlocks
+	if err := network.DecodePayload(payload, &gb); err != nil {
+		log.Println("ERROR: decode getblocks:", err)
+		return
+	}
+
+	var hashes [][]byte
+	if s.bc.GetTopHash() != nil {
+		it := s.bc.Iterator()
+		for {
+			block := it.Next()
+			hashes = append(hashes, block.Hash)
+			if len(block.PrevBlockHash) == 0 {
+				break
+			}
+		}
+	}
+
+	s.sendMessage(gb.AddrFrom, network.CmdInv, network.Inv{AddrFrom: s.nodeAddress, Type: "block", Items: hashes})
+}
+
+// handleInv requests any advertised block/tx we don't already have
+//
+// 对于inv中通告的区块或交易，如果本地没有，则发送getdata请求
+func (s *server) handleInv(payload []byte) {
+	var inv network.Inv
+	if err := network.DecodePayload(payload, &inv); err != nil {
+		log.Println("ERROR: decode inv:", err)
+		return
+	}
+
+	s.addKnownNode(inv.AddrFrom)
+
+	switch inv.Type {
+	case "block":
+		var missing [][]byte
+		for _, hash := range inv.Items {
+			if s.findBlock(hash) == nil {
+				missing = append(missing, hash)
+			}
+		}
+
+		// inv.Items walks newest-to-oldest (see handleGetBlocks), but blocks can
+		// only be applied oldest-first, since each one must link onto the tip
+		for i, j := 0, len(missing)-1; i < j; i, j = i+1, j-1 {
+			missing[i], missing[j] = missing[j], missing[i]
+		}
+
+		s.mu.Lock()
+		s.blocksInTransit = missing
+		s.mu.Unlock()
+
+		if len(missing) > 0 {
+			s.sendMessage(inv.AddrFrom, network.CmdGetData, network.GetData{AddrFrom: s.nodeAddress, Type: "block", ID: missing[0]})
+		}
+	case "tx":
+		for _, txID := range inv.Items {
+			s.mu.Lock()
+			_, known := s.mempool[string(txID)]
+			s.mu.Unlock()
+
+			if !known {
+				s.sendMessage(inv.AddrFrom, network.CmdGetData, network.GetData{AddrFrom: s.nodeAddress, Type: "tx", ID: txID})
+			}
+		}
+	}
+}
+
+// handleGetData replies with the requested block or transaction, if we have it
+//
+// 如果本地有请求的区块或交易，将其发送给请求方
+func (s *server) handleGetData(payload []byte) {
+	var gd network.GetData
+	if err := network.DecodePayload(payload, &gd); err != nil {
+		log.Println("ERROR: decode getdata:", err)
+		return
+	}
+
+	switch gd.Type {
+	case "block":
+		block := s.findBlock(gd.ID)
+		if block == nil {
+			return
+		}
+		s.sendMessage(gd.AddrFrom, network.CmdBlock, network.BlockData{AddrFrom: s.nodeAddress, Block: block.Serialize()})
+	case "tx":
+		s.mu.Lock()
+		tx, ok := s.mempool[string(gd.ID)]
+		s.mu.Unlock()
+		if !ok {
+			return
+		}
+		s.sendMessage(gd.AddrFrom, network.CmdTx, network.TxData{AddrFrom: s.nodeAddress, Transaction: tx.Serialize()})
+	}
+}
+
+// findBlock scans the chain looking for the block identified by hash
+//
+// 遍历整条链查找哈希为hash的区块
+func (s *server) findBlock(hash []byte) *Block {
+	if s.bc.GetTopHash() == nil {
+		return nil
+	}
+
+	it := s.bc.Iterator()
+	for {
+		block := it.Next()
+		if string(block.Hash) == string(hash) {
+			return block
+		}
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// handleBlock appends a received block to the chain and continues the sync if needed
+//
+// 将收到的区块追加到链上，如果还有未下载完的区块则继续同步
+func (s *server) handleBlock(payload []byte) {
+	var bd network.BlockData
+	if err := network.DecodePayload(payload, &bd); err != nil {
+		log.Println("ERROR: decode block:", err)
+		return
+	}
+
+	s.addKnownNode(bd.AddrFrom)
+
+	block, err := Deserialize(bd.Block)
+	if err != nil {
+		log.Println("ERROR: decode block payload from", bd.AddrFrom, ":", err)
+		return
+	}
+
+	if s.findBlock(block.Hash) == nil {
+		if err := s.bc.AddExistingBlock(block); err != nil {
+			log.Println("WARN: rejected block from", bd.AddrFrom, ":", err)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	if len(s.blocksInTransit) > 0 {
+		s.blocksInTransit = s.blocksInTransit[1:]
+	}
+	remaining := s.blocksInTransit
+	s.mu.Unlock()
+
+	if len(remaining) > 0 {
+		s.sendMessage(bd.AddrFrom, network.CmdGetData, network.GetData{AddrFrom: s.nodeAddress, Type: "block", ID: remaining[0]})
+	}
+}
+
+// handleTx adds a received transaction to the mempool and, once the mempool
+// threshold is reached, mines a block if this node is running as a miner
+//
+// 将收到的交易加入内存池；一旦内存池中的交易数量达到阈值，矿工节点就会挖出新区块
+func (s *server) handleTx(payload []byte) {
+	var td network.TxData
+	if err := network.DecodePayload(payload, &td); err != nil {
+		log.Println("ERROR: decode tx:", err)
+		return
+	}
+
+	var tx Transaction
+	if err := gobDecode(td.Transaction, &tx); err != nil {
+		log.Println("ERROR: decode tx payload:", err)
+		return
+	}
+
+	s.addKnownNode(td.AddrFrom)
+
+	s.mu.Lock()
+	s.mempool[string(tx.ID)] = &tx
+	pending := len(s.mempool)
+	s.mu.Unlock()
+
+	s.broadcastInv("tx", [][]byte{tx.ID})
+
+	if s.minerAddress != "" && pending >= mempoolThreshold {
+		s.mineMempool()
+	}
+}
+
+// mineMempool drains the mempool into a single new block, rewarding minerAddress
+//
+// 将内存池中的交易打包进一个新区块，并给minerAddress发放挖矿奖励
+func (s *server) mineMempool() {
+	s.mu.Lock()
+	txs := make([]*Transaction, 0, len(s.mempool))
+	for _, tx := range s.mempool {
+		if s.bc.VerifyTransaction(tx) {
+			txs = append(txs, tx)
+		}
+	}
+	s.mempool = make(map[string]*Transaction)
+	s.mu.Unlock()
+
+	if len(txs) == 0 {
+		return
+	}
+
+	// 混入当前链顶哈希，避免连续挖矿给同一地址产生重复的coinbase交易ID
+	coinbase := NewCoinbaseTX(s.minerAddress, fmt.Sprintf("Reward to '%s' after %x", s.minerAddress, s.bc.GetTopHash()))
+	txs = append(txs, coinbase)
+
+	// AddBlock triggers bc.onBlockAdded, which broadcasts the new tip to peers
+	if err := s.bc.AddBlock(txs); err != nil {
+		log.Println("WARN: failed to mine mempool:", err)
+	}
+}
+
+// handleAddr merges a received peer list into our known nodes
+//
+// 将收到的节点地址列表合并进自己已知的节点列表
+func (s *server) handleAddr(payload []byte) {
+	var addr network.Addr
+	if err := network.DecodePayload(payload, &addr); err != nil {
+		log.Println("ERROR: decode addr:", err)
+		return
+	}
+
+	for _, node := range addr.AddrList {
+		s.addKnownNode(node)
+	}
+}
+
+// broadcastInv advertises items of the given type to every known peer
+//
+// 向所有已知节点广播指定类型的inv
+func (s *server) broadcastInv(invType string, items [][]byte) {
+	for _, node := range s.knownNodesSnapshot() {
+		s.sendMessage(node, network.CmdInv, network.Inv{AddrFrom: s.nodeAddress, Type: invType, Items: items})
+	}
+}
+
+// broadcastBlockTip advertises the hash of our current top block to every known peer
+//
+// 向所有已知节点广播当前最新区块的哈希
+func (s *server) broadcastBlockTip() {
+	s.broadcastInv("block", [][]byte{s.bc.GetTopHash()})
+}
+
+// addKnownNode adds addr to the known peer list if it isn't already there
+//
+// 如果addr尚未在已知节点列表中，则加入该列表
+func (s *server) addKnownNode(addr string) {
+	if addr == "" || addr == s.nodeAddress {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, node := range s.knownNodes {
+		if node == addr {
+			return
+		}
+	}
+	s.knownNodes = append(s.knownNodes, addr)
+}
+
+// removeKnownNode drops addr from the known peer list, e.g. after a failed dial
+//
+// 将addr从已知节点列表中移除，例如在连接失败之后
+func (s *server) removeKnownNode(addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := s.knownNodes[:0]
+	for _, node := range s.knownNodes {
+		if node != addr {
+			updated = append(updated, node)
+		}
+	}
+	s.knownNodes = updated
+}
+
+// knownNodesSnapshot returns a copy of the known peer list safe to range over without holding the lock
+//
+// 返回已知节点列表的一份拷贝，调用方可以在不持有锁的情况下安全遍历
+func (s *server) knownNodesSnapshot() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nodes := make([]string, len(s.knownNodes))
+	copy(nodes, s.knownNodes)
+
+	return nodes
+}
+
+// gobDecode is a small helper decoding a gob-encoded byte slice into out
+//
+// 将gob编码的字节数组解码到out中的小工具函数
+func gobDecode(data []byte, out interface{}) error {
+	return network.DecodePayload(data, out)
+}
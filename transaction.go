@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// subsidy is the amount of reward a miner gets for mining a block
+//
+// 挖出一个区块后，矿工获得的coinbase奖励数量
+const subsidy = 10
+
+// TXInput represents a transaction input
+//
+// TXInput表示一笔交易的输入，引用了之前某笔交易的某个输出
+type TXInput struct {
+	TXid      []byte // 引用的上一笔交易的ID
+	Voutindex int    // 引用的上一笔交易中的输出索引
+	Signature []byte // 对裁剪后交易的ECDSA签名
+	PubKey    []byte // 花费者的原始公钥（未哈希）
+}
+
+// TXoutput represents a transaction output
+//
+// TXoutput表示一笔交易的输出，锁定给某个公钥哈希对应的地址
+type TXoutput struct {
+	Value      int    // 输出的金额
+	PubKeyHash []byte // 锁定该输出的公钥哈希，即HASH160(pubKey)
+}
+
+// Transaction represents a Bitcoin-style transaction
+//
+// Transaction表示一笔交易，包含若干输入和输出
+type Transaction struct {
+	ID  []byte     // 交易ID，即交易内容的哈希值
+	In  []TXInput  // 交易输入列表
+	Out []TXoutput // 交易输出列表
+}
+
+// Lock locks an output with the hash of the given address's public key
+//
+// 使用地址解码得到的公钥哈希锁定这笔输出
+func (out *TXoutput) Lock(address string) {
+	pubKeyHash := Base58Decode([]byte(address))
+	// 去掉版本字节和末尾4字节校验和，中间部分才是公钥哈希
+	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
+	out.PubKeyHash = pubKeyHash
+}
+
+// IsLockedWithKey checks whether the output is locked with the given public key hash
+//
+// 检查这笔输出是否由给定的公钥哈希锁定
+func (out *TXoutput) IsLockedWithKey(pubKeyHash []byte) bool {
+	return bytes.Equal(out.PubKeyHash, pubKeyHash)
+}
+
+// NewTXoutput creates a new transaction output locked to the given address
+//
+// 创建一笔新的交易输出，并锁定给指定地址
+func NewTXoutput(value int, address string) *TXoutput {
+	txo := &TXoutput{Value: value, PubKeyHash: nil}
+	txo.Lock(address)
+
+	return txo
+}
+
+// UsesKey checks whether the input was signed by the owner of the given public key hash
+//
+// 检查这笔输入使用的公钥是否对应给定的公钥哈希
+func (in *TXInput) UsesKey(pubKeyHash []byte) bool {
+	lockingHash := HashPubKey(in.PubKey)
+
+	return bytes.Equal(lockingHash, pubKeyHash)
+}
+
+// IsCoinbase checks whether the transaction is a coinbase transaction
+//
+// 判断是否是coinbase交易：只有一笔输入，且不引用任何之前的输出
+func (tx *Transaction) IsCoinbase() bool {
+	return len(tx.In) == 1 && len(tx.In[0].TXid) == 0 && tx.In[0].Voutindex == -1
+}
+
+// NewCoinbaseTX creates a new coinbase transaction rewarding the given address
+//
+// 创建一笔coinbase交易，奖励挖出区块的矿工
+func NewCoinbaseTX(to, data string) *Transaction {
+	if data == "" {
+		data = fmt.Sprintf("Reward to '%s'", to)
+	}
+
+	txin := TXInput{TXid: []byte{}, Voutindex: -1, Signature: nil, PubKey: []byte(data)}
+	txout := NewTXoutput(subsidy, to)
+
+	tx := Transaction{ID: nil, In: []TXInput{txin}, Out: []TXoutput{*txout}}
+	tx.ID = tx.Hash()
+
+	return &tx
+}
+
+// NewUTXOTransaction creates a new transaction transferring amount from from to to
+//
+// 创建一笔从from到to转账amount的交易，花费from名下足够数量的未花费输出，多余部分找零给from
+func NewUTXOTransaction(from, to string, amount int, bc *Blockchain) (*Transaction, error) {
+	var inputs []TXInput
+	var outputs []TXoutput
+
+	wallets, err := NewWallets()
+	if err != nil {
+		return nil, err
+	}
+	wallet := wallets.GetWallet(from)
+	pubKeyHash := HashPubKey(wallet.PublicKey)
+
+	utxoSet := UTXOSet{Blockchain: bc}
+	accumulated, validOutputs := utxoSet.FindSpendableOutputs(pubKeyHash, amount)
+
+	if accumulated < amount {
+		return nil, errors.New("ERROR: Not enough funds")
+	}
+
+	// build a list of inputs referencing the collected outputs
+	for txID, outs := range validOutputs {
+		txid := []byte(txID)
+
+		for _, outIdx := range outs {
+			input := TXInput{TXid: txid, Voutindex: outIdx, Signature: nil, PubKey: wallet.PublicKey}
+			inputs = append(inputs, input)
+		}
+	}
+
+	// build the output paying to
+	outputs = append(outputs, *NewTXoutput(amount, to))
+	// 如果累加的金额超过了amount，剩余部分找零给from
+	if accumulated > amount {
+		outputs = append(outputs, *NewTXoutput(accumulated-amount, from))
+	}
+
+	tx := Transaction{ID: nil, In: inputs, Out: outputs}
+	tx.ID = tx.Hash()
+
+	prevTXs := make(map[string]Transaction)
+	for _, in := range tx.In {
+		prevTX, err := bc.FindTransaction(in.TXid)
+		if err != nil {
+			return nil, err
+		}
+		prevTXs[string(prevTX.ID)] = prevTX
+	}
+
+	tx.Sign(wallet.PrivateKey, prevTXs)
+
+	return &tx, nil
+}
+
+// Serialize encodes the transaction into a byte array using gob
+//
+// 使用gob将交易编码为字节数组
+func (tx *Transaction) Serialize() []byte {
+	var encoded bytes.Buffer
+
+	enc := gob.NewEncoder(&encoded)
+	err := enc.Encode(tx)
+	if err != nil {
+		panic(err)
+	}
+
+	return encoded.Bytes()
+}
+
+// Hash returns the hash of the transaction after clearing its ID field
+//
+// 计算交易的哈希值，计算前先清空ID字段，避免自引用
+func (tx *Transaction) Hash() []byte {
+	txCopy := *tx
+	txCopy.ID = []byte{}
+
+	hash := sha256.Sum256(txCopy.Serialize())
+
+	return hash[:]
+}
+
+// TrimmedCopy returns a copy of the transaction with signatures and public keys stripped
+//
+// 返回一个裁剪后的交易副本：输入的Signature和PubKey都被清空，用于签名和验证时参与哈希计算
+func (tx *Transaction) TrimmedCopy() Transaction {
+	var inputs []TXInput
+	var outputs []TXoutput
+
+	for _, in := range tx.In {
+		inputs = append(inputs, TXInput{TXid: in.TXid, Voutindex: in.Voutindex, Signature: nil, PubKey: nil})
+	}
+
+	for _, out := range tx.Out {
+		outputs = append(outputs, out)
+	}
+
+	return Transaction{ID: tx.ID, In: inputs, Out: outputs}
+}
+
+// Sign signs each input of the transaction with the given private key
+//
+// 使用私钥对交易的每一笔输入进行签名，prevTXs是输入所引用的上一笔交易的集合
+func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) {
+	if tx.IsCoinbase() {
+		return
+	}
+
+	for _, in := range tx.In {
+		if prevTXs[string(in.TXid)].ID == nil {
+			panic("ERROR: Previous transaction is not correct")
+		}
+	}
+
+	txCopy := tx.TrimmedCopy()
+
+	for inID, in := range txCopy.In {
+		prevTx := prevTXs[string(in.TXid)]
+		// 签名时，将当前输入的PubKey临时替换为引用输出的PubKeyHash
+		txCopy.In[inID].Signature = nil
+		txCopy.In[inID].PubKey = prevTx.Out[in.Voutindex].PubKeyHash
+		txCopy.ID = txCopy.Hash()
+		txCopy.In[inID].PubKey = nil
+
+		r, s, err := ecdsa.Sign(rand.Reader, &privKey, txCopy.ID)
+		if err != nil {
+			panic(err)
+		}
+		// r、s各自按曲线字节长度左补0后拼接，Verify按一半切分才能正确还原
+		size := (privKey.Curve.Params().BitSize + 7) / 8
+		signature := make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+
+		tx.In[inID].Signature = signature
+	}
+}
+
+// Verify verifies the signatures of every input of the transaction
+//
+// 验证交易每一笔输入的签名是否合法，prevTXs是输入所引用的上一笔交易的集合
+func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
+	if tx.IsCoinbase() {
+		return true
+	}
+
+	for _, in := range tx.In {
+		if prevTXs[string(in.TXid)].ID == nil {
+			panic("ERROR: Previous transaction is not correct")
+		}
+	}
+
+	txCopy := tx.TrimmedCopy()
+	curve := elliptic.P256()
+
+	for inID, in := range tx.In {
+		prevTx := prevTXs[string(in.TXid)]
+		// 输入提供的公钥必须与被引用输出锁定的公钥哈希一致
+		if !in.UsesKey(prevTx.Out[in.Voutindex].PubKeyHash) {
+			return false
+		}
+		txCopy.In[inID].Signature = nil
+		txCopy.In[inID].PubKey = prevTx.Out[in.Voutindex].PubKeyHash
+		txCopy.ID = txCopy.Hash()
+		txCopy.In[inID].PubKey = nil
+
+		r := big.Int{}
+		s := big.Int{}
+		sigLen := len(in.Signature)
+		r.SetBytes(in.Signature[:(sigLen / 2)])
+		s.SetBytes(in.Signature[(sigLen / 2):])
+
+		x := big.Int{}
+		y := big.Int{}
+		keyLen := len(in.PubKey)
+		x.SetBytes(in.PubKey[:(keyLen / 2)])
+		y.SetBytes(in.PubKey[(keyLen / 2):])
+
+		rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
+		if !ecdsa.Verify(&rawPubKey, txCopy.ID, &r, &s) {
+			return false
+		}
+	}
+
+	return true
+}
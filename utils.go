@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// IntToHex converts an int64 into a byte array
+//
+// 将int64类型转换为字节数组，主要用于参与哈希计算
+func IntToHex(num int64) []byte {
+	buff := new(bytes.Buffer)
+	err := binary.Write(buff, binary.BigEndian, num)
+	if err != nil {
+		panic(err)
+	}
+
+	return buff.Bytes()
+}
+
+// ReverseBytes reverses a byte array
+//
+// 翻转字节数组，小端和大端转换时会用到
+func ReverseBytes(data []byte) {
+	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+		data[i], data[j] = data[j], data[i]
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"log"
+
+	"github.com/boltdb/bolt"
+)
+
+// utxoBucket is the name of the bucket caching the UTXO set
+//
+// 缓存UTXO集合的bucket名称
+const utxoBucket = "utxo"
+
+// UTXOSet caches the unspent transaction outputs of a Blockchain in a dedicated bucket
+//
+// UTXOSet在独立的bucket中缓存区块链的未花费交易输出，避免每次查询都扫描整条链
+type UTXOSet struct {
+	Blockchain *Blockchain
+}
+
+// serializeOutputs encodes a slice of TXoutput into a byte array using gob
+//
+// 使用gob将输出列表编码为字节数组
+func serializeOutputs(outs []TXoutput) []byte {
+	var buff bytes.Buffer
+
+	enc := gob.NewEncoder(&buff)
+	err := enc.Encode(outs)
+	if err != nil {
+		panic(err)
+	}
+
+	return buff.Bytes()
+}
+
+// deserializeOutputs decodes a byte array back into a slice of TXoutput
+//
+// 将字节数组解码为输出列表
+func deserializeOutputs(data []byte) []TXoutput {
+	var outs []TXoutput
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err := dec.Decode(&outs)
+	if err != nil {
+		panic(err)
+	}
+
+	return outs
+}
+
+// Reindex drops the cached UTXO bucket and rebuilds it by scanning the whole chain once
+//
+// 删除并重建UTXO bucket，重新扫描整条链，写入每笔交易中未花费的输出
+func (u UTXOSet) Reindex() {
+	db := u.Blockchain.db
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket([]byte(utxoBucket))
+		if err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+
+		_, err = tx.CreateBucket([]byte(utxoBucket))
+		return err
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	UTXO := u.Blockchain.FindAllUTXO()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(utxoBucket))
+
+		for txID, outs := range UTXO {
+			err := bucket.Put([]byte(txID), serializeOutputs(outs))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// FindSpendableOutputs greedily collects cached unspent outputs for pubKeyHash until amount is reached
+//
+// 直接从缓存的UTXO bucket中读取数据，贪心收集未花费输出直到累加金额达到amount
+func (u UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[string][]int) {
+	unspentOutputs := make(map[string][]int)
+	accumulated := 0
+	db := u.Blockchain.db
+
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(utxoBucket))
+		cursor := bucket.Cursor()
+
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			txID := string(k)
+			outs := deserializeOutputs(v)
+
+			for outIdx, out := range outs {
+				if out.IsLockedWithKey(pubKeyHash) && accumulated < amount {
+					accumulated += out.Value
+					unspentOutputs[txID] = append(unspentOutputs[txID], outIdx)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return accumulated, unspentOutputs
+}
+
+// FindUTXO returns all cached unspent outputs locked with pubKeyHash
+//
+// 从缓存的UTXO bucket中读取pubKeyHash名下的所有未花费输出
+func (u UTXOSet) FindUTXO(pubKeyHash []byte) []*TXoutput {
+	var UTXOs []*TXoutput
+	db := u.Blockchain.db
+
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(utxoBucket))
+		cursor := bucket.Cursor()
+
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			outs := deserializeOutputs(v)
+
+			for outIdx := range outs {
+				out := outs[outIdx]
+				if out.IsLockedWithKey(pubKeyHash) {
+					UTXOs = append(UTXOs, &out)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return UTXOs
+}
+
+// Update incrementally updates the cached UTXO set for the outputs spent/created by block
+//
+// 根据新区块中的交易，移除被花费的输出、写入新产生的输出，而不必重新扫描整条链
+func (u UTXOSet) Update(block *Block) {
+	db := u.Blockchain.db
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(utxoBucket))
+
+		for _, transaction := range block.Transactions {
+			if !transaction.IsCoinbase() {
+				for _, in := range transaction.In {
+					updatedOuts := []TXoutput{}
+					outsBytes := bucket.Get(in.TXid)
+					outs := deserializeOutputs(outsBytes)
+
+					for outIdx, out := range outs {
+						if outIdx != in.Voutindex {
+							updatedOuts = append(updatedOuts, out)
+						}
+					}
+
+					if len(updatedOuts) == 0 {
+						err := bucket.Delete(in.TXid)
+						if err != nil {
+							return err
+						}
+					} else {
+						err := bucket.Put(in.TXid, serializeOutputs(updatedOuts))
+						if err != nil {
+							return err
+						}
+					}
+				}
+			}
+
+			newOutputs := transaction.Out
+			err := bucket.Put(transaction.ID, serializeOutputs(newOutputs))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
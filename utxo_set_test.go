@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// withTempChainDir runs fn inside a temporary working directory so the test's
+// blockchain.db and wallets.dat never touch the real files used by the CLI.
+func withTempChainDir(t *testing.T, fn func()) {
+	t.Helper()
+
+	dir := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	fn()
+}
+
+func TestUTXOSetMatchesFullChainScan(t *testing.T) {
+	withTempChainDir(t, func() {
+		minerWallet := NewWallet()
+		bobWallet := NewWallet()
+		minerAddress := string(minerWallet.GetAddress())
+		bobAddress := string(bobWallet.GetAddress())
+
+		wallets := Wallets{Wallets: map[string]*Wallet{
+			minerAddress: minerWallet,
+			bobAddress:   bobWallet,
+		}}
+		wallets.SaveToFile()
+
+		bc := CreateBlockchain(minerAddress)
+		defer bc.db.Close()
+
+		// send a fixed sequence of transfers between miner and bob
+		transfers := []struct {
+			from, to string
+			amount   int
+		}{
+			{minerAddress, bobAddress, 4},
+			{minerAddress, bobAddress, 3},
+			{bobAddress, minerAddress, 2},
+		}
+
+		for _, tr := range transfers {
+			if err := bc.Send(tr.from, tr.to, tr.amount, minerAddress); err != nil {
+				t.Fatalf("Send(%s -> %s, %d) failed: %v", tr.from, tr.to, tr.amount, err)
+			}
+		}
+
+		utxoSet := UTXOSet{Blockchain: bc}
+
+		for _, address := range []string{minerAddress, bobAddress} {
+			pubKeyHash := Base58Decode([]byte(address))
+			pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
+
+			cachedBalance := 0
+			for _, out := range utxoSet.FindUTXO(pubKeyHash) {
+				cachedBalance += out.Value
+			}
+
+			scannedBalance := 0
+			for _, out := range bc.FindUTXO(pubKeyHash) {
+				scannedBalance += out.Value
+			}
+
+			if cachedBalance != scannedBalance {
+				t.Fatalf("balance mismatch for %s: cached=%d scanned=%d", address, cachedBalance, scannedBalance)
+			}
+		}
+	})
+}
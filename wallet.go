@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"math/big"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+// version is the address version byte, prepended before the public key hash
+//
+// 地址版本号，拼接在公钥哈希前面
+const version = byte(0x00)
+
+// addressChecksumLen is the length in bytes of the address checksum
+//
+// Base58Check地址末尾校验和的长度
+const addressChecksumLen = 4
+
+// Wallet stores a key pair used to sign transactions and derive an address
+//
+// Wallet保存一对密钥，用于签名交易和生成地址
+type Wallet struct {
+	PrivateKey ecdsa.PrivateKey // ECDSA私钥
+	PublicKey  []byte           // 未压缩的公钥，由X、Y坐标拼接而成
+}
+
+// newKeyPair generates a new ECDSA key pair over the P-256 curve
+//
+// 在P-256曲线上生成一对新的ECDSA密钥
+func newKeyPair() (ecdsa.PrivateKey, []byte) {
+	curve := elliptic.P256()
+	private, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	// X、Y各自按曲线字节长度左补0后拼接，Verify按一半切分才能正确还原坐标
+	size := (curve.Params().BitSize + 7) / 8
+	pubKey := make([]byte, 2*size)
+	private.PublicKey.X.FillBytes(pubKey[:size])
+	private.PublicKey.Y.FillBytes(pubKey[size:])
+
+	return *private, pubKey
+}
+
+// NewWallet creates and returns a Wallet with a freshly generated key pair
+//
+// 创建一个新的钱包，内部生成一对新的密钥
+func NewWallet() *Wallet {
+	private, public := newKeyPair()
+	wallet := Wallet{PrivateKey: private, PublicKey: public}
+
+	return &wallet
+}
+
+// walletGob is the on-disk representation of a Wallet: ecdsa.PrivateKey embeds
+// an elliptic.Curve interface (elliptic.p256Curve has no exported fields), so
+// gob-encoding a Wallet directly panics. Encoding the raw scalar/coordinates
+// instead and reconstructing the curve on decode avoids that.
+//
+// walletGob是Wallet在磁盘上的表示：ecdsa.PrivateKey内嵌了一个elliptic.Curve接口
+// （elliptic.p256Curve没有任何导出字段），直接对Wallet做gob编码会panic。
+// 改为编码私钥标量和公钥坐标的原始字节，解码时再重建曲线，即可避免这个问题
+type walletGob struct {
+	D, X, Y   []byte
+	PublicKey []byte
+}
+
+// GobEncode implements gob.GobEncoder so Wallet can be persisted safely
+//
+// GobEncode实现gob.GobEncoder接口，使Wallet可以被安全地持久化
+func (w Wallet) GobEncode() ([]byte, error) {
+	raw := walletGob{
+		D:         w.PrivateKey.D.Bytes(),
+		X:         w.PrivateKey.PublicKey.X.Bytes(),
+		Y:         w.PrivateKey.PublicKey.Y.Bytes(),
+		PublicKey: w.PublicKey,
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(raw); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder, rebuilding the P-256 curve that GobEncode dropped
+//
+// GobDecode实现gob.GobDecoder接口，重建GobEncode中被省略的P-256曲线
+func (w *Wallet) GobDecode(data []byte) error {
+	var raw walletGob
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&raw); err != nil {
+		return err
+	}
+
+	w.PrivateKey = ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(raw.X),
+			Y:     new(big.Int).SetBytes(raw.Y),
+		},
+		D: new(big.Int).SetBytes(raw.D),
+	}
+	w.PublicKey = raw.PublicKey
+
+	return nil
+}
+
+// GetAddress returns the Base58Check-encoded address for the wallet
+//
+// 根据钱包的公钥计算出Base58Check编码的地址
+func (w Wallet) GetAddress() []byte {
+	pubKeyHash := HashPubKey(w.PublicKey)
+
+	versionedPayload := append([]byte{version}, pubKeyHash...)
+	checksum := checksum(versionedPayload)
+
+	fullPayload := append(versionedPayload, checksum...)
+	address := Base58Encode(fullPayload)
+
+	return address
+}
+
+// HashPubKey hashes a public key with SHA-256 followed by RIPEMD-160
+//
+// 先做SHA256再做RIPEMD160，即HASH160算法
+func HashPubKey(pubKey []byte) []byte {
+	publicSHA256 := sha256.Sum256(pubKey)
+
+	RIPEMD160Hasher := ripemd160.New()
+	_, err := RIPEMD160Hasher.Write(publicSHA256[:])
+	if err != nil {
+		panic(err)
+	}
+	publicRIPEMD160 := RIPEMD160Hasher.Sum(nil)
+
+	return publicRIPEMD160
+}
+
+// checksum computes the double-SHA256 based checksum used in Base58Check addresses
+//
+// 对带版本号的payload做两次SHA256，取前addressChecksumLen字节作为校验和
+func checksum(payload []byte) []byte {
+	firstSHA := sha256.Sum256(payload)
+	secondSHA := sha256.Sum256(firstSHA[:])
+
+	return secondSHA[:addressChecksumLen]
+}
+
+// ValidateAddress checks whether the given address has a valid checksum
+//
+// 校验地址的校验和是否合法
+func ValidateAddress(address string) bool {
+	pubKeyHash := Base58Decode([]byte(address))
+	if len(pubKeyHash) < addressChecksumLen+1 {
+		return false
+	}
+
+	actualChecksum := pubKeyHash[len(pubKeyHash)-addressChecksumLen:]
+	version := pubKeyHash[0]
+	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
+	targetChecksum := checksum(append([]byte{version}, pubKeyHash...))
+
+	return bytes.Equal(actualChecksum, targetChecksum)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestWalletsSaveAndLoadFromFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	wallet := NewWallet()
+	address := string(wallet.GetAddress())
+
+	wallets := Wallets{Wallets: map[string]*Wallet{address: wallet}}
+	wallets.SaveToFile()
+
+	loaded, err := NewWallets()
+	if err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+
+	got := loaded.GetWallet(address)
+	if string(got.GetAddress()) != address {
+		t.Fatalf("expected loaded wallet to have address %q, got %q", address, got.GetAddress())
+	}
+	if got.PrivateKey.D.Cmp(wallet.PrivateKey.D) != 0 {
+		t.Fatalf("loaded private key D does not match the original")
+	}
+}
+
+func TestWalletAddressRoundTrip(t *testing.T) {
+	wallet := NewWallet()
+	address := string(wallet.GetAddress())
+
+	if !ValidateAddress(address) {
+		t.Fatalf("expected address %q to be valid", address)
+	}
+
+	pubKeyHash := Base58Decode([]byte(address))
+	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
+
+	if string(pubKeyHash) != string(HashPubKey(wallet.PublicKey)) {
+		t.Fatalf("decoded pubKeyHash does not match HashPubKey(wallet.PublicKey)")
+	}
+}
+
+func TestValidateAddressRejectsTamperedChecksum(t *testing.T) {
+	wallet := NewWallet()
+	address := wallet.GetAddress()
+
+	tampered := make([]byte, len(address))
+	copy(tampered, address)
+	tampered[len(tampered)-1]++
+
+	if ValidateAddress(string(tampered)) {
+		t.Fatalf("expected tampered address to fail validation")
+	}
+}
+
+func TestTransactionSignAndVerify(t *testing.T) {
+	walletA := NewWallet()
+	walletB := NewWallet()
+
+	prevTX := NewCoinbaseTX(string(walletA.GetAddress()), "")
+
+	txin := TXInput{TXid: prevTX.ID, Voutindex: 0, Signature: nil, PubKey: walletA.PublicKey}
+	txout := NewTXoutput(subsidy, string(walletB.GetAddress()))
+	tx := Transaction{ID: nil, In: []TXInput{txin}, Out: []TXoutput{*txout}}
+	tx.ID = tx.Hash()
+
+	prevTXs := map[string]Transaction{string(prevTX.ID): *prevTX}
+
+	tx.Sign(walletA.PrivateKey, prevTXs)
+
+	if !tx.Verify(prevTXs) {
+		t.Fatalf("expected a correctly signed transaction to verify")
+	}
+}
+
+func TestTransactionVerifyFailsOnTamper(t *testing.T) {
+	walletA := NewWallet()
+	walletB := NewWallet()
+
+	prevTX := NewCoinbaseTX(string(walletA.GetAddress()), "")
+
+	txin := TXInput{TXid: prevTX.ID, Voutindex: 0, Signature: nil, PubKey: walletA.PublicKey}
+	txout := NewTXoutput(subsidy, string(walletB.GetAddress()))
+	tx := Transaction{ID: nil, In: []TXInput{txin}, Out: []TXoutput{*txout}}
+	tx.ID = tx.Hash()
+
+	prevTXs := map[string]Transaction{string(prevTX.ID): *prevTX}
+	tx.Sign(walletA.PrivateKey, prevTXs)
+
+	// tamper with the output value after signing
+	tx.Out[0].Value = subsidy * 2
+
+	if tx.Verify(prevTXs) {
+		t.Fatalf("expected tampered transaction to fail verification")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// walletFile is the path to the file wallets are persisted to
+//
+// 钱包持久化存储的文件名
+const walletFile = "wallets.dat"
+
+// Wallets stores a collection of wallets indexed by address
+//
+// Wallets管理一组钱包，通过地址索引
+type Wallets struct {
+	Wallets map[string]*Wallet
+}
+
+// NewWallets creates a Wallets instance, loading any existing wallets from file
+//
+// 创建一个Wallets实例，如果钱包文件已存在则从中加载
+func NewWallets() (*Wallets, error) {
+	wallets := Wallets{}
+	wallets.Wallets = make(map[string]*Wallet)
+
+	err := wallets.LoadFromFile()
+
+	return &wallets, err
+}
+
+// CreateWallet generates a new wallet, adds it to the collection and returns its address
+//
+// 生成一个新钱包，加入集合并返回其地址
+func (ws *Wallets) CreateWallet() string {
+	wallet := NewWallet()
+	address := fmt.Sprintf("%s", wallet.GetAddress())
+
+	ws.Wallets[address] = wallet
+
+	return address
+}
+
+// GetAddresses returns the addresses of all wallets in the collection
+//
+// 返回集合中所有钱包的地址
+func (ws *Wallets) GetAddresses() []string {
+	var addresses []string
+
+	for address := range ws.Wallets {
+		addresses = append(addresses, address)
+	}
+
+	return addresses
+}
+
+// GetWallet returns the wallet for the given address
+//
+// 根据地址返回对应的钱包
+func (ws Wallets) GetWallet(address string) Wallet {
+	return *ws.Wallets[address]
+}
+
+// LoadFromFile loads wallets from the wallet file, if it exists
+//
+// 如果钱包文件存在，则从中加载所有钱包
+func (ws *Wallets) LoadFromFile() error {
+	if _, err := os.Stat(walletFile); os.IsNotExist(err) {
+		return nil
+	}
+
+	fileContent, err := ioutil.ReadFile(walletFile)
+	if err != nil {
+		return err
+	}
+
+	var wallets Wallets
+	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
+	err = decoder.Decode(&wallets)
+	if err != nil {
+		return err
+	}
+
+	ws.Wallets = wallets.Wallets
+
+	return nil
+}
+
+// SaveToFile persists all wallets to the wallet file
+//
+// 将所有钱包写入钱包文件
+func (ws Wallets) SaveToFile() {
+	var content bytes.Buffer
+
+	encoder := gob.NewEncoder(&content)
+	err := encoder.Encode(ws)
+	if err != nil {
+		panic(err)
+	}
+
+	err = ioutil.WriteFile(walletFile, content.Bytes(), 0600)
+	if err != nil {
+		panic(err)
+	}
+}